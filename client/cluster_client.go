@@ -0,0 +1,133 @@
+// Package client provides client-side helpers for talking to a lazarette
+// cluster.
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/byuoitav/lazarette/lazarette"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClusterClient wraps connections to every member of a lazarette cluster and
+// transparently retries Get/Set against another member when one is
+// unavailable, mirroring etcd's httpClusterClient endpoint-walking behavior.
+type ClusterClient struct {
+	conns   []*grpc.ClientConn
+	clients []lazarette.LazaretteClient
+}
+
+// NewClusterClient dials every address in addrs and returns a ClusterClient
+// that distributes requests across them.
+func NewClusterClient(addrs []string, opts ...grpc.DialOption) (*ClusterClient, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithInsecure()}
+	}
+
+	conns := make([]*grpc.ClientConn, 0, len(addrs))
+	clients := make([]lazarette.LazaretteClient, 0, len(addrs))
+	for _, addr := range addrs {
+		conn, err := grpc.Dial(addr, opts...)
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+
+			return nil, fmt.Errorf("unable to dial %q: %s", addr, err)
+		}
+
+		conns = append(conns, conn)
+		clients = append(clients, lazarette.NewLazaretteClient(conn))
+	}
+
+	return &ClusterClient{conns: conns, clients: clients}, nil
+}
+
+// Close closes every connection this ClusterClient wraps. It's safe to call
+// once after the client is no longer needed; it returns the first error
+// encountered, if any, but still attempts to close every connection.
+func (c *ClusterClient) Close() error {
+	var firstErr error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// shuffled returns the wrapped clients in a random order, so repeated calls
+// don't hammer the same member first every time.
+func (c *ClusterClient) shuffled() []lazarette.LazaretteClient {
+	order := rand.Perm(len(c.clients))
+	clients := make([]lazarette.LazaretteClient, len(c.clients))
+	for i, idx := range order {
+		clients[i] = c.clients[idx]
+	}
+
+	return clients
+}
+
+// Get tries each cluster member in shuffled order, retrying on Unavailable
+// or DeadlineExceeded, until one succeeds, one fails with a non-retryable
+// error, or ctx is canceled.
+func (c *ClusterClient) Get(ctx context.Context, key *lazarette.Key) (*lazarette.Value, error) {
+	var lastErr error
+
+	for _, client := range c.shuffled() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		val, err := client.Get(ctx, key)
+		if err == nil {
+			return val, nil
+		}
+
+		lastErr = err
+		if !retryable(err) {
+			return nil, err
+		}
+	}
+
+	return nil, lastErr
+}
+
+// Set tries each cluster member in shuffled order, retrying on Unavailable
+// or DeadlineExceeded, until one succeeds, one fails with a non-retryable
+// error, or ctx is canceled.
+func (c *ClusterClient) Set(ctx context.Context, kv *lazarette.KeyValue) error {
+	var lastErr error
+
+	for _, client := range c.shuffled() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		_, err := client.Set(ctx, kv)
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		if !retryable(err) {
+			return err
+		}
+	}
+
+	return lastErr
+}
+
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
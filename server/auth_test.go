@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/lazarette/lazarette"
+	"github.com/byuoitav/lazarette/log"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// newTestCA generates a self-signed CA for use by the TLS tests below.
+func newTestCA(tb testing.TB) (*x509.Certificate, *rsa.PrivateKey) {
+	tb.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatalf("failed to generate ca key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "lazarette-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		tb.Fatalf("failed to create ca cert: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		tb.Fatalf("failed to parse ca cert: %v", err)
+	}
+
+	return cert, key
+}
+
+// newTestLeafCert issues a cert/key pair signed by ca, suitable for use as
+// either a server or client certificate.
+func newTestLeafCert(tb testing.TB, ca *x509.Certificate, caKey *rsa.PrivateKey, serial int64, commonName string) (certPEM, keyPEM []byte) {
+	tb.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatalf("failed to generate leaf key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		tb.Fatalf("failed to create leaf cert: %v", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return certPEM, keyPEM
+}
+
+func writeTestFiles(tb testing.TB, files map[string][]byte) {
+	tb.Helper()
+
+	for path, data := range files {
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			tb.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+}
+
+func TestAuthRejection(t *testing.T) {
+	ctx := context.Background()
+
+	server := &Server{
+		Cache: newCache(t, log.P.Named(":7799")),
+		AuthFunc: func(ctx context.Context) (context.Context, error) {
+			return nil, errors.New("missing credentials")
+		},
+	}
+
+	go server.Serve(":7799", ":7800")
+	defer server.Stop(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("GRPCSetRejected", func(t *testing.T) {
+		client := newGRPCClient(t, "localhost:7799")
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		_, err := client.Set(ctx, &lazarette.KeyValue{
+			Key:       "ITB-1101-CP1",
+			Timestamp: ptypes.TimestampNow(),
+			Data:      []byte("nope"),
+		})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected an Unauthenticated error, got: %v", err)
+		}
+	})
+
+	t.Run("HTTPSetRejected", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPut, "http://localhost:7800/cache/ITB-1101-CP1", nil)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to make http request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected a 401, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestMutualTLSRequiresClientCert(t *testing.T) {
+	ctx := context.Background()
+
+	ca, caKey := newTestCA(t)
+	caCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	serverCertPEM, serverKeyPEM := newTestLeafCert(t, ca, caKey, 2, "localhost")
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server-key.pem")
+	writeTestFiles(t, map[string][]byte{caFile: caCertPEM, certFile: serverCertPEM, keyFile: serverKeyPEM})
+
+	server := &Server{
+		Cache: newCache(t, log.P.Named(":7811")),
+		TLS: &TLSConfig{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: caFile,
+		},
+	}
+
+	go server.Serve(":7811", "")
+	defer server.Stop(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caCertPEM)
+
+	// dial with no client certificate: the handshake should fail since
+	// ClientCAFile requires one.
+	conn, err := grpc.Dial("localhost:7811", grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})))
+	if err != nil {
+		t.Fatalf("unexpected dial error: %v", err)
+	}
+	defer conn.Close()
+
+	rctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := lazarette.NewLazaretteClient(conn).Get(rctx, &lazarette.Key{Key: "ITB-1101-CP1"}); err == nil {
+		t.Fatal("expected a handshake failure without a client certificate, got nil error")
+	}
+}
+
+func TestMutualTLSSucceedsWithClientCert(t *testing.T) {
+	ctx := context.Background()
+
+	ca, caKey := newTestCA(t)
+	caCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	serverCertPEM, serverKeyPEM := newTestLeafCert(t, ca, caKey, 3, "localhost")
+	clientCertPEM, clientKeyPEM := newTestLeafCert(t, ca, caKey, 4, "lazarette-test-client")
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server-key.pem")
+	writeTestFiles(t, map[string][]byte{caFile: caCertPEM, certFile: serverCertPEM, keyFile: serverKeyPEM})
+
+	server := &Server{
+		Cache: newCache(t, log.P.Named(":7812")),
+		TLS: &TLSConfig{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: caFile,
+		},
+	}
+
+	go server.Serve(":7812", "")
+	defer server.Stop(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatalf("failed to load client keypair: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caCertPEM)
+
+	client := newGRPCClientTLS(t, "localhost:7812", &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      pool,
+	})
+
+	kv := &lazarette.KeyValue{
+		Key:       "ITB-1101-CP1",
+		Timestamp: ptypes.TimestampNow(),
+		Data:      []byte("tls-ok"),
+	}
+
+	sctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := client.Set(sctx, kv); err != nil {
+		t.Fatalf("failed to set over mtls: %v", err)
+	}
+
+	val, err := client.Get(sctx, &lazarette.Key{Key: kv.GetKey()})
+	if err != nil {
+		t.Fatalf("failed to get over mtls: %v", err)
+	}
+
+	checkValueEqual(t, kv.GetKey(), &lazarette.Value{Timestamp: kv.GetTimestamp(), Data: kv.GetData()}, val)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+
+	"github.com/byuoitav/lazarette/lazarette"
+	"github.com/byuoitav/lazarette/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// subscriberBufferSize bounds how many updates a slow subscriber can fall
+// behind by before the oldest queued update is dropped in favor of the
+// newest one.
+const subscriberBufferSize = 64
+
+// subscription is a bounded, drop-oldest channel of key/value updates
+// delivered to one HTTP subscriber.
+type subscription struct {
+	ch chan *lazarette.KeyValue
+}
+
+func (s *subscription) send(kv *lazarette.KeyValue) {
+	select {
+	case s.ch <- kv:
+		return
+	default:
+	}
+
+	// channel's full: drop the oldest update to make room for this one.
+	select {
+	case <-s.ch:
+	default:
+	}
+
+	select {
+	case s.ch <- kv:
+	default:
+	}
+}
+
+// grpcSubStream adapts a subscription into the grpc.ServerStream interface
+// expected by lazarette.Cache.Subscribe, so HTTP subscribers can ride the
+// same fan-out path as gRPC ones instead of duplicating it.
+type grpcSubStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	sub *subscription
+}
+
+func (g *grpcSubStream) Context() context.Context {
+	return g.ctx
+}
+
+func (g *grpcSubStream) Send(kv *lazarette.KeyValue) error {
+	g.sub.send(kv)
+	return nil
+}
+
+// subscribe starts feeding prefix's matching updates from the Cache's
+// Subscribe stream into the returned channel, which is closed once ctx is
+// canceled or the underlying stream ends.
+func (s *Server) subscribe(ctx context.Context, prefix string) <-chan *lazarette.KeyValue {
+	sub := &subscription{ch: make(chan *lazarette.KeyValue, subscriberBufferSize)}
+	stream := &grpcSubStream{ctx: ctx, sub: sub}
+
+	go func() {
+		defer close(sub.ch)
+
+		if err := s.Cache.Subscribe(&lazarette.Key{Key: prefix}, stream); err != nil {
+			log.P.Warn("subscription ended", zap.String("prefix", prefix), zap.Error(err))
+		}
+	}()
+
+	return sub.ch
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/lazarette/lazarette"
+	"github.com/byuoitav/lazarette/log"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// TestSubscribeSSE makes sure a key set through the cache shows up as an SSE
+// frame on a concurrent /subscribe stream, exercising the broker end to end.
+func TestSubscribeSSE(t *testing.T) {
+	ctx := context.Background()
+
+	server := startServer(t, newCache(t, log.P.Named(":7860")), ":7861", ":7860")
+	defer server.Stop(ctx)
+
+	sctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(sctx, http.MethodGet, "http://localhost:7860/subscribe?prefix=ITB-1101-", nil)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open subscribe stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	frames := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "data: ") {
+				frames <- strings.TrimPrefix(line, "data: ")
+				return
+			}
+		}
+	}()
+
+	// give the subscriber a moment to register before publishing.
+	time.Sleep(200 * time.Millisecond)
+
+	client := newGRPCClient(t, "localhost:7861")
+
+	cctx, ccancel := context.WithTimeout(ctx, 5*time.Second)
+	defer ccancel()
+
+	_, err = client.Set(cctx, &lazarette.KeyValue{
+		Key:       "ITB-1101-CP1",
+		Timestamp: ptypes.TimestampNow(),
+		Data:      []byte("broker-test"),
+	})
+	if err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+
+	select {
+	case frame := <-frames:
+		if !strings.Contains(frame, "ITB-1101-CP1") {
+			t.Fatalf("expected subscribe frame for ITB-1101-CP1, got: %s", frame)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a subscribe frame")
+	}
+}
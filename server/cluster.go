@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/lazarette/lazarette"
+	"github.com/byuoitav/lazarette/log"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Cluster gossips this node's peer set with a list of seed peers and keeps a
+// bidirectional ReplicateWith stream running with every peer it discovers,
+// turning a single lazarette node into an eventually-consistent cache mesh.
+type Cluster struct {
+	Cache *lazarette.Cache
+
+	// TLS, if set, is used to dial every peer during gossip instead of a
+	// plaintext connection. It should match the TLSConfig the Server this
+	// Cluster belongs to is listening with - once a node's own listener
+	// requires TLS, its gossip dials against peers (presumably configured
+	// the same way) need to present and verify certificates too.
+	TLS *TLSConfig
+
+	// SelfAddr is the gRPC address other nodes should dial to reach this
+	// node; it's gossiped out in ListPeers responses.
+	SelfAddr string
+
+	// SeedPeers are the gRPC addresses used to bootstrap peer discovery.
+	SeedPeers []string
+
+	// Prefix scopes replication with every discovered peer to keys sharing
+	// this prefix. An empty prefix replicates everything.
+	Prefix string
+
+	// GossipInterval controls how often ListPeers is called against each
+	// known peer. Defaults to 30 seconds.
+	GossipInterval time.Duration
+
+	mu    sync.Mutex
+	peers map[string]bool
+}
+
+// Start begins gossiping with SeedPeers and replicating with every peer
+// discovered along the way. It blocks until ctx is canceled.
+func (c *Cluster) Start(ctx context.Context) {
+	c.mu.Lock()
+	c.peers = make(map[string]bool)
+	c.mu.Unlock()
+
+	c.addPeers(ctx, c.SeedPeers)
+
+	interval := c.GossipInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.gossip(ctx)
+		}
+	}
+}
+
+// Members returns the gRPC addresses of every peer currently known to this
+// node.
+func (c *Cluster) Members() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	members := make([]string, 0, len(c.peers))
+	for p := range c.peers {
+		members = append(members, p)
+	}
+
+	return members
+}
+
+// gossip asks every known peer for its own peer list via ListPeers, adding
+// any addresses it hasn't seen before.
+func (c *Cluster) gossip(ctx context.Context) {
+	for _, p := range c.Members() {
+		gctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+
+		opts := []grpc.DialOption{grpc.WithBlock()}
+		if c.TLS != nil {
+			tlsConfig, err := c.TLS.clientConfig()
+			if err != nil {
+				log.P.Warn("unable to build tls config for gossip", zap.String("peer", p), zap.Error(err))
+				cancel()
+				continue
+			}
+
+			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+		} else {
+			opts = append(opts, grpc.WithInsecure())
+		}
+
+		conn, err := grpc.DialContext(gctx, p, opts...)
+		if err != nil {
+			log.P.Warn("unable to gossip with peer", zap.String("peer", p), zap.Error(err))
+			cancel()
+			continue
+		}
+
+		resp, err := lazarette.NewLazaretteClient(conn).ListPeers(injectTraceMetadata(gctx), &lazarette.ListPeersRequest{})
+		conn.Close()
+		cancel()
+		if err != nil {
+			log.P.Warn("unable to list peers", zap.String("peer", p), zap.Error(err))
+			continue
+		}
+
+		c.addPeers(ctx, resp.GetAddrs())
+	}
+}
+
+// addPeers records any addresses in addrs that aren't already known (and
+// aren't this node) and starts replicating with each of them.
+func (c *Cluster) addPeers(ctx context.Context, addrs []string) {
+	c.mu.Lock()
+	var added []string
+	for _, a := range addrs {
+		if len(a) == 0 || a == c.SelfAddr || c.peers[a] {
+			continue
+		}
+
+		c.peers[a] = true
+		added = append(added, a)
+	}
+	c.mu.Unlock()
+
+	for _, a := range added {
+		log.P.Info("discovered new peer", zap.String("peer", a))
+		go c.replicateWith(ctx, a)
+	}
+}
+
+func (c *Cluster) replicateWith(ctx context.Context, peer string) {
+	if _, err := c.Cache.ReplicateWith(injectTraceMetadata(ctx), &lazarette.Replication{
+		RemoteAddr: peer,
+		Prefix:     c.Prefix,
+	}); err != nil {
+		log.P.Warn("replication with peer ended", zap.String("peer", peer), zap.Error(err))
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/lazarette/log"
+)
+
+// TestClusterMembers spins up two nodes, seeds node2 off of node1, and
+// confirms node1 shows up in node2's gossiped peer set and is served back
+// at GET /cluster/members.
+func TestClusterMembers(t *testing.T) {
+	ctx := context.Background()
+
+	cluster1 := &Cluster{
+		Cache:          newCache(t, log.P.Named(":7841")),
+		SelfAddr:       "localhost:7841",
+		GossipInterval: 500 * time.Millisecond,
+	}
+	server1 := &Server{Cache: cluster1.Cache, Cluster: cluster1}
+	go server1.Serve(":7841", "")
+	defer server1.Stop(ctx)
+
+	cluster2 := &Cluster{
+		Cache:          newCache(t, log.P.Named(":7850")),
+		SelfAddr:       "localhost:7850",
+		SeedPeers:      []string{"localhost:7841"},
+		GossipInterval: 500 * time.Millisecond,
+	}
+	server2 := &Server{Cache: cluster2.Cache, Cluster: cluster2}
+	go server2.Serve(":7850", ":7851")
+	defer server2.Stop(ctx)
+
+	var members []string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get("http://localhost:7851/cluster/members")
+		if err == nil {
+			json.NewDecoder(resp.Body).Decode(&members)
+			resp.Body.Close()
+		}
+
+		if len(members) > 0 {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if len(members) == 0 {
+		t.Fatal("expected node2 to have discovered node1 as a peer, got none")
+	}
+
+	var found bool
+	for _, m := range members {
+		if m == "localhost:7841" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected localhost:7841 among node2's members, got: %v", members)
+	}
+}
+
+// TestClusterMembersTLS is TestClusterMembers with both nodes' listeners
+// (and therefore their gossip dials) secured with mutual TLS, making sure
+// node2 still lists its seed node1 at GET /cluster/members when that
+// endpoint is itself served over mutual TLS.
+func TestClusterMembersTLS(t *testing.T) {
+	ctx := context.Background()
+
+	ca, caKey := newTestCA(t)
+	caCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	cert1PEM, key1PEM := newTestLeafCert(t, ca, caKey, 5, "localhost")
+	cert2PEM, key2PEM := newTestLeafCert(t, ca, caKey, 6, "localhost")
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	cert1File := filepath.Join(dir, "node1.pem")
+	key1File := filepath.Join(dir, "node1-key.pem")
+	cert2File := filepath.Join(dir, "node2.pem")
+	key2File := filepath.Join(dir, "node2-key.pem")
+	writeTestFiles(t, map[string][]byte{
+		caFile:    caCertPEM,
+		cert1File: cert1PEM,
+		key1File:  key1PEM,
+		cert2File: cert2PEM,
+		key2File:  key2PEM,
+	})
+
+	tls1 := &TLSConfig{CertFile: cert1File, KeyFile: key1File, ClientCAFile: caFile}
+	tls2 := &TLSConfig{CertFile: cert2File, KeyFile: key2File, ClientCAFile: caFile}
+
+	cluster1 := &Cluster{
+		Cache:          newCache(t, log.P.Named(":7842")),
+		SelfAddr:       "localhost:7842",
+		GossipInterval: 500 * time.Millisecond,
+		TLS:            tls1,
+	}
+	server1 := &Server{Cache: cluster1.Cache, Cluster: cluster1, TLS: tls1}
+	go server1.Serve(":7842", "")
+	defer server1.Stop(ctx)
+
+	cluster2 := &Cluster{
+		Cache:          newCache(t, log.P.Named(":7852")),
+		SelfAddr:       "localhost:7852",
+		SeedPeers:      []string{"localhost:7842"},
+		GossipInterval: 500 * time.Millisecond,
+		TLS:            tls2,
+	}
+	server2 := &Server{Cache: cluster2.Cache, Cluster: cluster2, TLS: tls2}
+	go server2.Serve(":7852", ":7853")
+	defer server2.Stop(ctx)
+
+	// node2's HTTP listener requires a client certificate signed by the CA.
+	clientCert, err := tls.X509KeyPair(cert2PEM, key2PEM)
+	if err != nil {
+		t.Fatalf("failed to load client keypair: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caCertPEM)
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      pool,
+	}}}
+
+	var members []string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := httpClient.Get("https://localhost:7853/cluster/members")
+		if err == nil {
+			json.NewDecoder(resp.Body).Decode(&members)
+			resp.Body.Close()
+		}
+
+		if len(members) > 0 {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if len(members) == 0 {
+		t.Fatal("expected node2 to have discovered node1 as a peer over tls, got none")
+	}
+
+	var found bool
+	for _, m := range members {
+		if m == "localhost:7842" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected localhost:7842 among node2's members, got: %v", members)
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metricsCollector records per-RPC counts and latencies across both the gRPC
+// and HTTP listeners. It's exported at GET /metrics in Prometheus text
+// format via promhttp.
+type metricsCollector struct {
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+}
+
+// collector and registerCollector ensure the underlying prometheus vectors
+// are only ever created and registered once per process: multiple *Server
+// instances with Metrics enabled (as the test suite already runs) share one
+// set of series instead of panicking on duplicate registration.
+var (
+	collector         *metricsCollector
+	registerCollector sync.Once
+)
+
+func newMetricsCollector() *metricsCollector {
+	registerCollector.Do(func() {
+		collector = &metricsCollector{
+			requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "lazarette",
+				Name:      "requests_total",
+				Help:      "Total number of requests handled, by transport, method, and result code.",
+			}, []string{"transport", "method", "code"}),
+			requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: "lazarette",
+				Name:      "request_duration_seconds",
+				Help:      "Request latency in seconds, by transport and method.",
+			}, []string{"transport", "method"}),
+		}
+
+		prometheus.MustRegister(collector.requestsTotal, collector.requestDuration)
+	})
+
+	return collector
+}
+
+func (m *metricsCollector) observe(transport, method, code string, dur time.Duration) {
+	m.requestsTotal.WithLabelValues(transport, method, code).Inc()
+	m.requestDuration.WithLabelValues(transport, method).Observe(dur.Seconds())
+}
+
+func (s *Server) unaryMetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.metrics.observe("grpc", info.FullMethod, status.Code(err).String(), time.Since(start))
+	return resp, err
+}
+
+func (s *Server) streamMetricsInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	s.metrics.observe("grpc", info.FullMethod, status.Code(err).String(), time.Since(start))
+	return err
+}
+
+// httpMetricsMiddleware records the same counters/latencies as the gRPC
+// interceptors, keyed by the matched echo route rather than the raw path so
+// /cache/:key doesn't explode into one series per key.
+func (s *Server) httpMetricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		method := c.Request().Method + " " + c.Path()
+		s.metrics.observe("http", method, strconv.Itoa(c.Response().Status), time.Since(start))
+
+		return err
+	}
+}
+
+// chainUnary combines multiple unary interceptors into the single one
+// grpc.UnaryInterceptor accepts, running them in order.
+func chainUnary(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chain := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chain
+			chain = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+
+		return chain(ctx, req)
+	}
+}
+
+// chainStream combines multiple stream interceptors into the single one
+// grpc.StreamInterceptor accepts, running them in order.
+func chainStream(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		chain := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chain
+			chain = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+
+		return chain(srv, ss)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/lazarette/lazarette"
+	"github.com/byuoitav/lazarette/log"
+	"github.com/golang/protobuf/ptypes"
+)
+
+// TestMetricsEndpoint exercises a request through both listeners and checks
+// it's reflected at GET /metrics. It also starts a second Metrics-enabled
+// Server in the same process, which would panic on duplicate prometheus
+// registration if newMetricsCollector's sync.Once guard regressed.
+func TestMetricsEndpoint(t *testing.T) {
+	ctx := context.Background()
+
+	server1 := &Server{Cache: newCache(t, log.P.Named(":7871")), Metrics: true}
+	go server1.Serve(":7871", ":7872")
+	defer server1.Stop(ctx)
+
+	server2 := &Server{Cache: newCache(t, log.P.Named(":7873")), Metrics: true}
+	go server2.Serve(":7873", ":7874")
+	defer server2.Stop(ctx)
+
+	time.Sleep(100 * time.Millisecond)
+
+	client := newGRPCClient(t, "localhost:7871")
+
+	cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := client.Set(cctx, &lazarette.KeyValue{
+		Key:       "ITB-1101-CP1",
+		Timestamp: ptypes.TimestampNow(),
+		Data:      []byte("metrics-test"),
+	})
+	if err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+
+	resp, err := http.Get("http://localhost:7872/metrics")
+	if err != nil {
+		t.Fatalf("failed to fetch metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics response: %v", err)
+	}
+
+	if !strings.Contains(string(buf), "lazarette_requests_total") {
+		t.Fatalf("expected lazarette_requests_total in metrics output, got:\n%s", buf)
+	}
+}
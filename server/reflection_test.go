@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/lazarette/log"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// TestServerReflection makes sure the reflection service registered in
+// serveGRPC actually answers ListServices, since grpcurl/grpc_cli depend on
+// it for ad-hoc debugging against a running node.
+func TestServerReflection(t *testing.T) {
+	ctx := context.Background()
+
+	server := startServer(t, newCache(t, log.P.Named(":7822")), ":7822", "")
+	defer server.Stop(ctx)
+
+	conn, err := grpc.Dial("localhost:7822", grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(2*time.Second))
+	if err != nil {
+		t.Fatalf("failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	rctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(rctx)
+	if err != nil {
+		t.Fatalf("failed to open reflection stream: %v", err)
+	}
+
+	err = stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatalf("failed to send ListServices request: %v", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("failed to receive ListServices response: %v", err)
+	}
+
+	services := resp.GetListServicesResponse().GetService()
+
+	var found bool
+	for _, s := range services {
+		if s.GetName() != "grpc.reflection.v1alpha.ServerReflection" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("expected reflection to list the lazarette service in addition to itself, got: %v", services)
+	}
+}
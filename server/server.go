@@ -2,23 +2,162 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/byuoitav/lazarette/lazarette"
 	"github.com/byuoitav/lazarette/log"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/gorilla/websocket"
 	"github.com/labstack/echo"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
+// TLSConfig holds the certificate used to secure a listener. If ClientCAFile
+// is set, the listener requires and verifies client certificates signed by
+// it, enabling mutual TLS.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+
+	ClientCAFile string
+}
+
+func (t *TLSConfig) tlsConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load keypair: %s", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if len(t.ClientCAFile) > 0 {
+		pem, err := ioutil.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client ca file: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("unable to parse client ca file")
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// clientConfig returns a *tls.Config suitable for dialing another node
+// secured with this same TLSConfig: it presents this node's own certificate
+// and trusts peers whose certificate was signed by ClientCAFile, mirroring
+// the mutual trust the listener itself requires of callers.
+func (t *TLSConfig) clientConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load keypair: %s", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if len(t.ClientCAFile) > 0 {
+		pem, err := ioutil.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client ca file: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("unable to parse client ca file")
+		}
+
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
 // Server .
 type Server struct {
 	Cache *lazarette.Cache
 
-	grpc *grpc.Server
-	echo *echo.Echo
+	// TLS configures the certificate used to secure both listeners. If nil,
+	// both listeners are served in plaintext.
+	TLS *TLSConfig
+
+	// AuthFunc, if set, is run against the auth credentials (a bearer token
+	// or API key) on every Set/Subscribe/ReplicateWith request - gRPC and
+	// HTTP alike - and must return an error to reject the request.
+	AuthFunc func(ctx context.Context) (context.Context, error)
+
+	// KeepaliveParams controls how often the server pings idle
+	// Subscribe/ReplicateWith streams to keep NATs/LBs from dropping them.
+	KeepaliveParams keepalive.ServerParameters
+
+	// KeepalivePolicy controls how aggressive a client is allowed to be with
+	// its own keepalive pings before the server tears down the connection.
+	KeepalivePolicy keepalive.EnforcementPolicy
+
+	// Cluster, if set, is gossiping with peers and replicating with the ones
+	// it discovers. It backs the /cluster/members endpoint.
+	Cluster *Cluster
+
+	// Metrics, when true, installs interceptors/middleware that record
+	// per-RPC counts and latencies, served at GET /metrics in Prometheus
+	// text format.
+	Metrics bool
+
+	// Tracing, when true, propagates an OpenTelemetry span context through
+	// gRPC metadata, so a replication chain across peers shows up as a
+	// single trace.
+	Tracing bool
+
+	grpc        *grpc.Server
+	echo        *echo.Echo
+	metrics     *metricsCollector
+	stopCluster context.CancelFunc
+}
+
+// authMethods lists the RPCs (and their HTTP equivalents) that AuthFunc
+// protects.
+var authMethods = []string{"Set", "Subscribe", "ReplicateWith"}
+
+func requiresAuth(fullMethod string) bool {
+	for _, m := range authMethods {
+		if strings.HasSuffix(fullMethod, "/"+m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func bearerToken(header string) string {
+	return strings.TrimPrefix(header, "Bearer ")
 }
 
 // Serve .
@@ -27,6 +166,17 @@ func (s *Server) Serve(grpcAddr string, httpAddr string) {
 		log.P.Fatal("must pass at least one address to bind to")
 	}
 
+	if s.Metrics {
+		s.metrics = newMetricsCollector()
+	}
+
+	if s.Cluster != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopCluster = cancel
+
+		go s.Cluster.Start(ctx)
+	}
+
 	wg := &sync.WaitGroup{}
 
 	if len(grpcAddr) > 0 {
@@ -64,34 +214,408 @@ func (s *Server) Serve(grpcAddr string, httpAddr string) {
 func (s *Server) serveGRPC(l net.Listener, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	s.grpc = grpc.NewServer()
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(s.KeepaliveParams),
+		grpc.KeepaliveEnforcementPolicy(s.KeepalivePolicy),
+	}
+
+	if s.TLS != nil {
+		config, err := s.TLS.tlsConfig()
+		if err != nil {
+			log.P.Fatal("failed to build grpc tls config", zap.Error(err))
+		}
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+	}
+
+	var unaryInterceptors []grpc.UnaryServerInterceptor
+	var streamInterceptors []grpc.StreamServerInterceptor
+
+	// metrics wraps every other interceptor so that a rejection further in
+	// (e.g. auth) still shows up in requests_total/request_duration_seconds,
+	// matching the HTTP side where httpMetricsMiddleware is global echo
+	// middleware wrapping the per-route httpAuthMiddleware.
+	if s.Metrics {
+		unaryInterceptors = append(unaryInterceptors, s.unaryMetricsInterceptor)
+		streamInterceptors = append(streamInterceptors, s.streamMetricsInterceptor)
+	}
+
+	if s.Tracing {
+		unaryInterceptors = append(unaryInterceptors, s.unaryTracingInterceptor)
+		streamInterceptors = append(streamInterceptors, s.streamTracingInterceptor)
+	}
+
+	if s.AuthFunc != nil {
+		unaryInterceptors = append(unaryInterceptors, s.unaryAuthInterceptor)
+		streamInterceptors = append(streamInterceptors, s.streamAuthInterceptor)
+	}
+
+	if len(unaryInterceptors) > 0 {
+		opts = append(opts, grpc.UnaryInterceptor(chainUnary(unaryInterceptors...)))
+	}
+
+	if len(streamInterceptors) > 0 {
+		opts = append(opts, grpc.StreamInterceptor(chainStream(streamInterceptors...)))
+	}
+
+	s.grpc = grpc.NewServer(opts...)
 	lazarette.RegisterLazaretteServer(s.grpc, s.Cache)
 
+	// enable grpcurl/grpc_cli introspection for debugging replication issues
+	reflection.Register(s.grpc)
+
 	if err := s.grpc.Serve(l); err != nil {
 		log.P.Fatal("failed to serve grpc", zap.Error(err))
 	}
 }
 
+func (s *Server) unaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !requiresAuth(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	ctx, err := s.authenticateGRPC(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !requiresAuth(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	ctx, err := s.authenticateGRPC(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+}
+
+func (s *Server) authenticateGRPC(ctx context.Context) (context.Context, error) {
+	var token string
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get("authorization"); len(vals) > 0 {
+			token = bearerToken(vals[0])
+		}
+	}
+
+	nctx, err := s.AuthFunc(context.WithValue(ctx, authTokenKey{}, token))
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "authentication failed: %s", err)
+	}
+
+	return nctx, nil
+}
+
+// authServerStream wraps a grpc.ServerStream so that a stream interceptor can
+// swap in the context returned by AuthFunc.
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authServerStream) Context() context.Context {
+	return a.ctx
+}
+
+// authTokenKey is the context key the bearer token/API key is stored under
+// before being passed to AuthFunc.
+type authTokenKey struct{}
+
 func (s *Server) serveHTTP(l net.Listener, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	if s.TLS != nil {
+		config, err := s.TLS.tlsConfig()
+		if err != nil {
+			log.P.Fatal("failed to build http tls config", zap.Error(err))
+		}
+
+		l = tls.NewListener(l, config)
+	}
+
 	s.echo = echo.New()
 	s.echo.HideBanner = true
 	s.echo.HidePort = true
 	s.echo.Listener = l
 
-	// TODO add endpoints here
+	if s.Metrics {
+		s.echo.Use(s.httpMetricsMiddleware)
+		s.echo.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+	}
+
 	s.echo.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "hello!")
 	})
 
+	s.echo.PUT("/cache/:key", s.httpSetKey, s.httpAuthMiddleware)
+	s.echo.GET("/cache/:key", s.httpGetKey)
+	s.echo.DELETE("/cache/:key", s.httpDeleteKey)
+	s.echo.POST("/cache/clean", s.httpCleanCache)
+	s.echo.GET("/cache", s.httpListKeys)
+
+	s.echo.GET("/replication", s.httpGetReplications)
+	s.echo.POST("/replication", s.httpStartReplication, s.httpAuthMiddleware)
+
+	s.echo.GET("/cluster/members", s.httpClusterMembers)
+
+	s.echo.GET("/subscribe", s.httpSubscribeSSE, s.httpAuthMiddleware)
+	s.echo.GET("/subscribe/ws", s.httpSubscribeWS, s.httpAuthMiddleware)
+
 	if err := s.echo.Start(""); err != nil {
 		log.P.Fatal("failed to serve http", zap.Error(err))
 	}
 }
 
+// httpAuthMiddleware runs AuthFunc (if set) against the request's
+// Authorization header before allowing it through to the handler.
+func (s *Server) httpAuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.AuthFunc == nil {
+			return next(c)
+		}
+
+		token := bearerToken(c.Request().Header.Get("Authorization"))
+
+		ctx, err := s.AuthFunc(context.WithValue(c.Request().Context(), authTokenKey{}, token))
+		if err != nil {
+			return c.String(http.StatusUnauthorized, fmt.Sprintf("authentication failed: %s", err))
+		}
+
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// httpSetKey handles PUT /cache/:key, setting key to the request body. The
+// Last-Modified header (if present) is used as the value's timestamp.
+func (s *Server) httpSetKey(c echo.Context) error {
+	key := c.Param("key")
+
+	data, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("unable to read body: %s", err))
+	}
+
+	tstamp := ptypes.TimestampNow()
+	if lm := c.Request().Header.Get("Last-Modified"); len(lm) > 0 {
+		t, err := time.Parse(time.RFC3339Nano, lm)
+		if err != nil {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("invalid Last-Modified header: %s", err))
+		}
+
+		pt, err := ptypes.TimestampProto(t)
+		if err != nil {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("invalid Last-Modified header: %s", err))
+		}
+
+		tstamp = pt
+	}
+
+	_, err = s.Cache.Set(c.Request().Context(), &lazarette.KeyValue{
+		Key:       key,
+		Timestamp: tstamp,
+		Data:      data,
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("unable to set %q: %s", key, err))
+	}
+
+	return c.String(http.StatusOK, fmt.Sprintf("updated %s", key))
+}
+
+// httpGetKey handles GET /cache/:key, returning the raw value with its
+// timestamp in the Last-Modified header.
+func (s *Server) httpGetKey(c echo.Context) error {
+	key := c.Param("key")
+
+	val, err := s.Cache.Get(c.Request().Context(), &lazarette.Key{Key: key})
+	if err != nil {
+		return c.String(http.StatusNotFound, fmt.Sprintf("unable to get %q: %s", key, err))
+	}
+
+	tstamp, err := ptypes.Timestamp(val.GetTimestamp())
+	if err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("invalid timestamp for %q: %s", key, err))
+	}
+
+	c.Response().Header().Set("Last-Modified", tstamp.Format(time.RFC3339Nano))
+	return c.Blob(http.StatusOK, http.DetectContentType(val.GetData()), val.GetData())
+}
+
+// httpDeleteKey handles DELETE /cache/:key.
+func (s *Server) httpDeleteKey(c echo.Context) error {
+	key := c.Param("key")
+
+	if _, err := s.Cache.Delete(c.Request().Context(), &lazarette.Key{Key: key}); err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("unable to delete %q: %s", key, err))
+	}
+
+	return c.String(http.StatusOK, fmt.Sprintf("deleted %s", key))
+}
+
+// httpCleanCache handles POST /cache/clean, wiping the entire cache.
+func (s *Server) httpCleanCache(c echo.Context) error {
+	if err := s.Cache.Clean(); err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("unable to clean cache: %s", err))
+	}
+
+	return c.String(http.StatusOK, "cleaned cache")
+}
+
+// httpListKeys handles GET /cache?prefix=, listing the keys matching prefix.
+func (s *Server) httpListKeys(c echo.Context) error {
+	keys, err := s.Cache.Keys(c.Request().Context(), &lazarette.Key{Key: c.QueryParam("prefix")})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("unable to list keys: %s", err))
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// httpGetReplications handles GET /replication, listing the replications
+// currently running on this node.
+func (s *Server) httpGetReplications(c echo.Context) error {
+	reps, err := s.Cache.GetReplications(c.Request().Context(), &empty.Empty{})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, fmt.Sprintf("unable to get replications: %s", err))
+	}
+
+	return c.JSON(http.StatusOK, reps)
+}
+
+// httpStartReplication handles POST /replication, starting a new replication
+// with the remote described in the request body.
+func (s *Server) httpStartReplication(c echo.Context) error {
+	var rep lazarette.Replication
+	if err := c.Bind(&rep); err != nil {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("invalid replication request: %s", err))
+	}
+
+	go func() {
+		if _, err := s.Cache.ReplicateWith(context.Background(), &rep); err != nil {
+			log.P.Warn("replication ended", zap.String("remote", rep.GetRemoteAddr()), zap.Error(err))
+		}
+	}()
+
+	return c.String(http.StatusOK, fmt.Sprintf("started replication with %s", rep.GetRemoteAddr()))
+}
+
+// subscribeFrame is the JSON shape delivered to both SSE and websocket
+// subscribers.
+type subscribeFrame struct {
+	Key       string `json:"key"`
+	Timestamp string `json:"timestamp"`
+	Data      string `json:"data-base64"`
+}
+
+func newSubscribeFrame(kv *lazarette.KeyValue) subscribeFrame {
+	var tstamp string
+	if t, err := ptypes.Timestamp(kv.GetTimestamp()); err == nil {
+		tstamp = t.Format(time.RFC3339Nano)
+	}
+
+	return subscribeFrame{
+		Key:       kv.GetKey(),
+		Timestamp: tstamp,
+		Data:      base64.StdEncoding.EncodeToString(kv.GetData()),
+	}
+}
+
+// httpSubscribeSSE handles GET /subscribe?prefix=, streaming matching
+// updates as Server-Sent Events for as long as the client stays connected.
+func (s *Server) httpSubscribeSSE(c echo.Context) error {
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+
+	ch := s.subscribe(ctx, c.QueryParam("prefix"))
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	for kv := range ch {
+		buf, err := json.Marshal(newSubscribeFrame(kv))
+		if err != nil {
+			log.P.Warn("unable to marshal subscribe frame", zap.Error(err))
+			continue
+		}
+
+		if _, err := fmt.Fprintf(res, "data: %s\n\n", buf); err != nil {
+			return nil
+		}
+
+		res.Flush()
+	}
+
+	return nil
+}
+
+// wsUpgrader upgrades /subscribe/ws connections; origin checking is left to
+// whatever's in front of lazarette, same as the rest of the HTTP surface.
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// httpSubscribeWS handles GET /subscribe/ws?prefix=, delivering the same
+// frames as httpSubscribeSSE over a websocket connection.
+func (s *Server) httpSubscribeWS(c echo.Context) error {
+	conn, err := wsUpgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+
+	// read pump: we don't expect the client to send anything, but we still
+	// need to read to process control frames (ping/pong/close) and to
+	// notice an abrupt disconnect instead of blocking on the next write.
+	go func() {
+		defer cancel()
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ch := s.subscribe(ctx, c.QueryParam("prefix"))
+
+	for kv := range ch {
+		if err := conn.WriteJSON(newSubscribeFrame(kv)); err != nil {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// httpClusterMembers handles GET /cluster/members, listing the peers
+// currently known to this node's Cluster.
+func (s *Server) httpClusterMembers(c echo.Context) error {
+	if s.Cluster == nil {
+		return c.JSON(http.StatusOK, []string{})
+	}
+
+	return c.JSON(http.StatusOK, s.Cluster.Members())
+}
+
 // Stop .
 func (s *Server) Stop(ctx context.Context) error {
+	if s.stopCluster != nil {
+		s.stopCluster()
+	}
+
 	if s.grpc != nil {
 		s.grpc.Stop()
 	}
@@ -104,4 +628,4 @@ func (s *Server) Stop(ctx context.Context) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
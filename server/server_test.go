@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func newCache(tb testing.TB, logger *zap.Logger) *lazarette.Cache {
@@ -65,6 +67,19 @@ func newGRPCClient(tb testing.TB, address string) lazarette.LazaretteClient {
 	return lazarette.NewLazaretteClient(conn)
 }
 
+func newGRPCClientTLS(tb testing.TB, address string, tlsConfig *tls.Config) lazarette.LazaretteClient {
+	tb.Helper()
+
+	creds := credentials.NewTLS(tlsConfig)
+
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(creds), grpc.WithBlock(), grpc.WithTimeout(2*time.Second))
+	if err != nil {
+		tb.Fatalf("failed to connect to server over tls: %v", err)
+	}
+
+	return lazarette.NewLazaretteClient(conn)
+}
+
 func checkValueEqual(tb testing.TB, key string, expected, actual *lazarette.Value) {
 	if !proto.Equal(expected, actual) {
 		tb.Fatalf("values don't match for key %q:\n\texpected: %s\n\tactual: %s\n", key, expected.String(), actual.String())
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataCarrier adapts grpc metadata.MD to otel's TextMapCarrier so span
+// contexts can ride along in gRPC metadata, letting a replication chain
+// across peers show up as a single trace.
+type metadataCarrier metadata.MD
+
+func (m metadataCarrier) Get(key string) string {
+	if vals := metadata.MD(m).Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+
+	return ""
+}
+
+func (m metadataCarrier) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// injectTraceMetadata stamps ctx's current span context into its outgoing
+// gRPC metadata, for calls (ReplicateWith, ListPeers) made to other peers.
+func injectTraceMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		md = metadata.MD{}
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+func (s *Server) unaryTracingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = extractTraceMetadata(ctx)
+
+	ctx, span := otel.Tracer("lazarette").Start(ctx, info.FullMethod)
+	defer span.End()
+
+	return handler(ctx, req)
+}
+
+func (s *Server) streamTracingInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := extractTraceMetadata(ss.Context())
+
+	ctx, span := otel.Tracer("lazarette").Start(ctx, info.FullMethod)
+	defer span.End()
+
+	return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+}
+
+func extractTraceMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+}
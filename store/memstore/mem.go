@@ -1,20 +1,95 @@
 package memstore
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/byuoitav/lazarette/store"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// storeCount gives every memstore instance in this process a distinct
+// "instance" label on its metrics, since Get/Put/Size are reported per
+// store rather than globally.
+var storeCount uint64
+
+// metrics holds the prometheus vectors shared by every memstore instance in
+// this process. registerMetrics ensures they're only ever created and
+// registered once, the same way server.newMetricsCollector guards against
+// duplicate registration across multiple *Server instances.
+type metrics struct {
+	hits   *prometheus.CounterVec
+	misses *prometheus.CounterVec
+	puts   *prometheus.CounterVec
+	size   *prometheus.GaugeVec
+}
+
+var (
+	storeMetrics    *metrics
+	registerMetrics sync.Once
+)
+
+func newMetrics() *metrics {
+	registerMetrics.Do(func() {
+		storeMetrics = &metrics{
+			hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "lazarette",
+				Subsystem: "memstore",
+				Name:      "hits_total",
+				Help:      "Total number of Get calls that found a value, by store instance.",
+			}, []string{"instance"}),
+			misses: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "lazarette",
+				Subsystem: "memstore",
+				Name:      "misses_total",
+				Help:      "Total number of Get calls that found nothing, by store instance.",
+			}, []string{"instance"}),
+			puts: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "lazarette",
+				Subsystem: "memstore",
+				Name:      "puts_total",
+				Help:      "Total number of Put calls, by store instance.",
+			}, []string{"instance"}),
+			size: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Namespace: "lazarette",
+				Subsystem: "memstore",
+				Name:      "size",
+				Help:      "Number of keys currently held, by store instance.",
+			}, []string{"instance"}),
+		}
+
+		prometheus.MustRegister(storeMetrics.hits, storeMetrics.misses, storeMetrics.puts, storeMetrics.size)
+	})
+
+	return storeMetrics
+}
+
 type memstore struct {
 	mu sync.RWMutex
 	m  map[string][]byte
+
+	hits   uint64
+	misses uint64
+	puts   uint64
+
+	metricHits   prometheus.Counter
+	metricMisses prometheus.Counter
+	metricPuts   prometheus.Counter
+	metricSize   prometheus.Gauge
 }
 
 // NewStore .
 func NewStore() (store.Store, error) {
+	m := newMetrics()
+	instance := fmt.Sprintf("memstore-%d", atomic.AddUint64(&storeCount, 1))
+
 	return &memstore{
-		m: make(map[string][]byte),
+		m:            make(map[string][]byte),
+		metricHits:   m.hits.WithLabelValues(instance),
+		metricMisses: m.misses.WithLabelValues(instance),
+		metricPuts:   m.puts.WithLabelValues(instance),
+		metricSize:   m.size.WithLabelValues(instance),
 	}, nil
 }
 
@@ -27,6 +102,11 @@ func (s *memstore) Get(key []byte) ([]byte, error) {
 	if data, ok := s.m[string(key)]; ok {
 		val = make([]byte, len(data))
 		copy(val, data)
+		atomic.AddUint64(&s.hits, 1)
+		s.metricHits.Inc()
+	} else {
+		atomic.AddUint64(&s.misses, 1)
+		s.metricMisses.Inc()
 	}
 
 	return val, nil
@@ -35,9 +115,13 @@ func (s *memstore) Get(key []byte) ([]byte, error) {
 // Put .
 func (s *memstore) Put(key, val []byte) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	s.m[string(key)] = val
+	s.mu.Unlock()
+
+	atomic.AddUint64(&s.puts, 1)
+	s.metricPuts.Inc()
+	s.metricSize.Set(float64(s.Size()))
+
 	return nil
 }
 
@@ -47,10 +131,29 @@ func (s *memstore) Clean() error {
 	s.m = make(map[string][]byte)
 	s.mu.Unlock()
 
+	s.metricSize.Set(float64(s.Size()))
+
 	return nil
 }
 
 // Close .
 func (s *memstore) Close() error {
 	return nil
-}
\ No newline at end of file
+}
+
+// Size returns the number of keys currently in the store.
+func (s *memstore) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.m)
+}
+
+// Stats returns this store's cumulative hit/miss/put counts.
+func (s *memstore) Stats() store.Stats {
+	return store.Stats{
+		Hits:   atomic.LoadUint64(&s.hits),
+		Misses: atomic.LoadUint64(&s.misses),
+		Puts:   atomic.LoadUint64(&s.puts),
+	}
+}
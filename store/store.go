@@ -0,0 +1,30 @@
+// Package store defines the interface lazarette's cache uses to persist
+// key/value data. memstore (in this module) is one implementation;
+// syncmapstore and boltstore are others used elsewhere in this project but
+// aren't part of this checkout.
+package store
+
+// Store persists and retrieves raw key/value data for a Cache. Get returns
+// a nil value and a nil error for a key that isn't present.
+type Store interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, val []byte) error
+	Clean() error
+	Close() error
+
+	// Size returns the number of keys currently held by the store.
+	Size() int
+
+	// Stats returns a snapshot of the store's cumulative Get/Put counts,
+	// so callers (e.g. per-store metrics) don't have to track them
+	// separately.
+	Stats() Stats
+}
+
+// Stats is a point-in-time snapshot of a Store's cumulative operation
+// counts.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	Puts   uint64
+}